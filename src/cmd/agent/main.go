@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/giulian-coding/kubervise/internal/agent"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func main() {
+	// Ohne --kubeconfig nutzt clientcmd automatisch die In-Cluster-Config des Pods
+	kubeconfig := flag.String("kubeconfig", "", "(optional) absolute path to the kubeconfig file, empty for in-cluster config")
+	apiURL := flag.String("api-url", os.Getenv("KUBERVISE_API_URL"), "base URL of the Kubervise API")
+	interval := flag.Duration("interval", 5*time.Minute, "interval between two inventory syncs")
+	namespaces := flag.String("namespaces", "", "comma-separated namespaces to collect inventory and events from, empty for all")
+	metrics := flag.Bool("metrics", true, "report pod and node metrics")
+	events := flag.Bool("events", true, "stream warning events to the API")
+	cost := flag.Bool("cost", true, "report cost estimates")
+	scanInterval := flag.Duration("scan-interval", 0, "interval between two image vulnerability scans with trivy, 0 to disable")
+	leaderElect := flag.Bool("leader-elect", false, "use a Lease so only one of several replicas reports")
+	leaseName := flag.String("lease-name", "kubervise-agent", "name of the Lease used for leader election")
+	remoteActions := flag.Bool("remote-actions", false, "accept allowlisted actions from the API over an outbound WebSocket")
+	kubeletFallback := flag.Bool("kubelet-fallback", false, "read metrics from the kubelet summary API when metrics-server is missing (needs nodes/proxy)")
+	configMap := flag.String("config-map", "kubervise-agent", "name of the agent ConfigMap in the agent's namespace")
+	flag.Parse()
+
+	// Das Token kommt absichtlich nur aus der Umgebung (Secret), damit es nicht in der Prozessliste steht
+	token := os.Getenv("KUBERVISE_AGENT_TOKEN")
+	if *apiURL == "" || token == "" {
+		log.Fatal("KUBERVISE_API_URL (oder --api-url) und KUBERVISE_AGENT_TOKEN müssen gesetzt sein")
+	}
+
+	// time.NewTicker panict bei Intervallen <= 0
+	if *interval <= 0 {
+		log.Fatalf("--interval muss größer als 0 sein, ist aber %s", *interval)
+	}
+	if *scanInterval < 0 {
+		log.Fatalf("--scan-interval darf nicht negativ sein, ist aber %s", *scanInterval)
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if err != nil {
+		log.Fatalf("Fehler beim Laden der K8s-Config: %v", err)
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("Fehler beim Erstellen des K8s Clients: %v", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("Fehler beim Erstellen des K8s Clientsets: %v", err)
+	}
+
+	// Ohne eindeutige Identität kann die Lease keinem Replika zugeordnet werden (RunOrDie würde panicen)
+	identity := agentIdentity()
+	if *leaderElect && identity == "" {
+		log.Fatal("--leader-elect braucht eine Identität: POD_NAME setzen (Downward API) oder einen Hostnamen bereitstellen")
+	}
+
+	// Sauber beenden, wenn Kubernetes den Pod stoppt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Die Flags sind nur Defaults, die Agent-ConfigMap kann sie zur Laufzeit überschreiben
+	a := agent.NewAgent(client, clientset, agent.Config{
+		APIURL: *apiURL,
+		Token:  token,
+
+		ConfigMapNamespace: agentNamespace(),
+		ConfigMapName:      *configMap,
+
+		LeaderElection: *leaderElect,
+		LeaseName:      *leaseName,
+		Identity:       identity,
+
+		RemoteActions:   *remoteActions,
+		KubeletFallback: *kubeletFallback,
+
+		Defaults: agent.Settings{
+			Interval:     *interval,
+			Namespaces:   agent.SplitList(*namespaces),
+			LogLevel:     "info",
+			Metrics:      *metrics,
+			Events:       *events,
+			Cost:         *cost,
+			ScanInterval: *scanInterval,
+		},
+	})
+
+	log.Printf("🚀 Agent gestartet, meldet alle %s an %s", *interval, *apiURL)
+	if err := a.Run(ctx); err != nil {
+		log.Fatalf("Agent beendet mit Fehler: %v", err)
+	}
+}
+
+// agentIdentity ist der Pod-Name (per Downward API), außerhalb des Clusters der Hostname
+func agentIdentity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		log.Printf("Hostname nicht lesbar: %v", err)
+		return ""
+	}
+	return name
+}
+
+// agentNamespace ermittelt den eigenen Namespace (per Downward API oder aus dem ServiceAccount)
+func agentNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	if ns, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+	return "kubervise-system"
+}
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Agent sammelt das Cluster-Inventar und meldet es an die Kubervise-API
+type Agent struct {
+	client    dynamic.Interface
+	clientset kubernetes.Interface // Für alles, was der dynamische Client nicht kann (z.B. Kubelet-Proxy)
+	api       *apiClient
+	config    Config
+
+	current atomic.Pointer[Settings] // Aktuelle Einstellungen, werden bei ConfigMap-Änderungen ersetzt
+	reload  chan struct{}            // Signalisiert der Run-Schleife geänderte Einstellungen
+}
+
+// NewAgent ist der "Konstruktor" für den In-Cluster-Agenten
+func NewAgent(client dynamic.Interface, clientset kubernetes.Interface, config Config) *Agent {
+	a := &Agent{
+		client:    client,
+		clientset: clientset,
+		api:       newAPIClient(config.APIURL, config.Token, config.Identity),
+		config:    config,
+		reload:    make(chan struct{}, 1),
+	}
+	defaults := config.Defaults
+	a.current.Store(&defaults)
+	return a
+}
+
+// Run synchronisiert sofort und danach in jedem Intervall, bis der Context beendet wird.
+// Mit Leader Election übernimmt das nur die Replika, die gerade Leader ist.
+func (a *Agent) Run(ctx context.Context) error {
+	// leaderelection.RunOrDie panict ohne Identität
+	if a.config.LeaderElection && a.config.Identity == "" {
+		return fmt.Errorf("leader election braucht eine Identität (Config.Identity)")
+	}
+
+	// Auch Standbys halten ihre Einstellungen aktuell, damit sie bei einer Übernahme sofort richtig arbeiten
+	a.loadSettings(ctx)
+	go a.watchSettings(ctx)
+
+	if a.config.LeaderElection {
+		a.runWithLeaderElection(ctx)
+	} else {
+		a.lead(ctx)
+	}
+	return nil
+}
+
+// lead startet alle Collectors und synchronisiert im Intervall, bis ctx endet (Shutdown oder verlorene Leitung)
+func (a *Agent) lead(ctx context.Context) {
+	// Die Log-Sammlung liest ihre Einstellungen bei jeder Suche selbst neu
+	go a.tailLogs(ctx)
+	if a.config.RemoteActions {
+		go a.serveRemoteActions(ctx)
+	}
+
+	// Events und Scan laufen mit eigenem Context, damit sie bei Konfigurationsänderungen neu starten können
+	var stopEvents, stopScan context.CancelFunc
+	s := a.settings()
+	restartWorker(ctx, &stopEvents, s.Events, a.streamEvents)
+	restartWorker(ctx, &stopScan, s.ScanInterval > 0, a.scanImages)
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	a.syncAndLog(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.syncAndLog(ctx)
+		case <-a.reload:
+			prev := s
+			s = a.settings()
+
+			ticker.Reset(s.Interval)
+			if s.Events != prev.Events || !slices.Equal(s.Namespaces, prev.Namespaces) {
+				restartWorker(ctx, &stopEvents, s.Events, a.streamEvents)
+			}
+			if s.ScanInterval != prev.ScanInterval {
+				restartWorker(ctx, &stopScan, s.ScanInterval > 0, a.scanImages)
+			}
+		}
+	}
+}
+
+// restartWorker beendet einen laufenden Hintergrund-Worker und startet ihn bei Bedarf neu
+func restartWorker(ctx context.Context, stop *context.CancelFunc, enabled bool, run func(context.Context)) {
+	if *stop != nil {
+		(*stop)()
+		*stop = nil
+	}
+	if !enabled {
+		return
+	}
+
+	workerCtx, cancel := context.WithCancel(ctx)
+	*stop = cancel
+	go run(workerCtx)
+}
+
+// syncAndLog führt einen Sync aus. Ein Fehler beendet den Agenten nicht, der nächste Tick versucht es erneut.
+func (a *Agent) syncAndLog(ctx context.Context) {
+	if err := a.Sync(ctx); err != nil {
+		log.Printf("Sync fehlgeschlagen: %v", err)
+	}
+}
+
+// Sync baut einen Inventar-Snapshot und schickt ihn zusammen mit Metriken und Kostenschätzung an die API
+func (a *Agent) Sync(ctx context.Context) error {
+	s := a.settings()
+
+	snapshot, err := a.collectInventory(ctx, s.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	if err := a.api.post(ctx, "/api/v1/agent/inventory", snapshot); err != nil {
+		return fmt.Errorf("fehler beim Melden des Inventars: %w", err)
+	}
+
+	log.Printf("Inventar gemeldet: %d Nodes, %d Namespaces, %d Deployments, %d Pods",
+		len(snapshot.Nodes), len(snapshot.Namespaces), len(snapshot.Deployments), len(snapshot.Pods))
+
+	// Fehlende Metriken sind kein Grund, das Inventar zu verwerfen
+	var metrics *Metrics
+	if s.Metrics {
+		metrics, err = a.collectMetrics(ctx, s.Namespaces)
+		if err != nil {
+			log.Printf("Metriken nicht verfügbar: %v", err)
+		} else if err := a.api.post(ctx, "/api/v1/agent/metrics", metrics); err != nil {
+			return fmt.Errorf("fehler beim Melden der Metriken: %w", err)
+		}
+	}
+
+	if !s.Cost {
+		return nil
+	}
+
+	// Ohne Metriken schätzen wir die Kosten nur anhand der Requests
+	cost, err := a.estimateCost(ctx, metrics, s.Pricing, s.Namespaces)
+	if err != nil {
+		return fmt.Errorf("fehler bei der Kostenschätzung: %w", err)
+	}
+	if err := a.api.post(ctx, "/api/v1/agent/cost", cost); err != nil {
+		return fmt.Errorf("fehler beim Melden der Kosten: %w", err)
+	}
+	return nil
+}
+
+// debugf loggt nur, wenn in der ConfigMap log.level=debug gesetzt ist
+func (a *Agent) debugf(format string, args ...interface{}) {
+	if a.settings().LogLevel == "debug" {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiClient spricht mit dem Kubervise-Backend
+type apiClient struct {
+	baseURL  string
+	token    string
+	identity string // Welche Replika meldet, damit das Backend den aktuellen Leader kennt
+	http     *http.Client
+}
+
+func newAPIClient(baseURL, token, identity string) *apiClient {
+	return &apiClient{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		token:    token,
+		identity: identity,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// post schickt einen Payload als JSON an den angegebenen API-Pfad
+func (c *apiClient) post(ctx context.Context, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("fehler beim Serialisieren für %s: %w", path, err)
+	}
+	return c.send(ctx, path, body, "")
+}
+
+// postCompressed schickt den Payload gzip-komprimiert, gedacht für große Datenmengen wie Logs
+func (c *apiClient) postCompressed(ctx context.Context, path string, payload interface{}) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(payload); err != nil {
+		return fmt.Errorf("fehler beim Serialisieren für %s: %w", path, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("fehler beim Komprimieren für %s: %w", path, err)
+	}
+	return c.send(ctx, path, buf.Bytes(), "gzip")
+}
+
+func (c *apiClient) send(ctx context.Context, path string, body []byte, encoding string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if c.identity != "" {
+		req.Header.Set("X-Kubervise-Agent", c.identity)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("fehler beim Senden an %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("API antwortete mit %d auf %s: %s", resp.StatusCode, path, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
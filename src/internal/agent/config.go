@@ -0,0 +1,201 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Config bündelt die Start-Einstellungen des Agenten
+type Config struct {
+	APIURL string
+	Token  string
+
+	// Agent-ConfigMap mit Einstellungen, die ohne Neustart geändert werden können
+	ConfigMapNamespace string
+	ConfigMapName      string
+
+	// Mit LeaderElection arbeitet bei mehreren Replikas nur der Inhaber der Lease LeaseName.
+	// Identity muss pro Replika eindeutig sein (z.B. der Pod-Name).
+	LeaderElection bool
+	LeaseName      string
+	Identity       string
+
+	// Ausgehender WebSocket-Kanal, über den das Backend Aktionen aus einer festen Allowlist anfragen kann
+	RemoteActions bool
+
+	// Ohne metrics-server die Kubelet Summary API über den Node-Proxy abfragen (braucht nodes/proxy)
+	KubeletFallback bool
+
+	Defaults Settings // Gelten, solange die ConfigMap nichts anderes sagt
+}
+
+// Settings sind die Einstellungen, die der Agent aus seiner ConfigMap übernimmt und zur Laufzeit ändern kann
+type Settings struct {
+	Interval   time.Duration // Abstand zwischen zwei Inventar-Syncs
+	Namespaces []string      // Beobachtete Namespaces für Inventar, Events und Logs, leer bedeutet: alle
+	LogLevel   string        // "info" oder "debug"
+
+	// Collectors an/aus
+	Metrics      bool
+	Events       bool
+	Cost         bool
+	ScanInterval time.Duration // Abstand zwischen zwei Image-Scans, 0 schaltet den Scan ab
+
+	Logs    LogConfig
+	Pricing *Pricing // nil bedeutet: Standardpreise des erkannten Cloud-Anbieters
+}
+
+// parseSettings übernimmt die Einträge der ConfigMap über die Defaults. Fehlende Schlüssel behalten den Default.
+//
+//	sync.interval: "5m"           namespaces: "shop,billing"     log.level: "debug"
+//	collectors.metrics: "true"    collectors.events: "true"      collectors.cost: "true"
+//	scan.interval: "6h"           logs.enabled / logs.namespaces / logs.denyNamespaces / logs.selector
+//	cost.cpuCoreHour: "0.03"      cost.memoryGiBHour: "0.004"
+func parseSettings(data map[string]string, defaults Settings) (Settings, error) {
+	s := defaults
+
+	var err error
+	if v, ok := data["sync.interval"]; ok && v != "" {
+		if s.Interval, err = time.ParseDuration(v); err != nil || s.Interval <= 0 {
+			return defaults, fmt.Errorf("sync.interval %q ist keine gültige Dauer", v)
+		}
+	}
+	if v, ok := data["namespaces"]; ok {
+		s.Namespaces = SplitList(v)
+	}
+	if v, ok := data["log.level"]; ok && v != "" {
+		if v != "info" && v != "debug" {
+			return defaults, fmt.Errorf("log.level %q ist weder info noch debug", v)
+		}
+		s.LogLevel = v
+	}
+
+	for key, target := range map[string]*bool{
+		"collectors.metrics": &s.Metrics,
+		"collectors.events":  &s.Events,
+		"collectors.cost":    &s.Cost,
+		"logs.enabled":       &s.Logs.Enabled,
+	} {
+		if v, ok := data[key]; ok && v != "" {
+			if *target, err = strconv.ParseBool(v); err != nil {
+				return defaults, fmt.Errorf("%s %q ist kein Boolean", key, v)
+			}
+		}
+	}
+
+	if v, ok := data["scan.interval"]; ok && v != "" {
+		if s.ScanInterval, err = time.ParseDuration(v); err != nil || s.ScanInterval < 0 {
+			return defaults, fmt.Errorf("scan.interval %q ist keine gültige Dauer", v)
+		}
+	}
+
+	if v, ok := data["logs.namespaces"]; ok {
+		s.Logs.Namespaces = SplitList(v)
+	}
+	if v, ok := data["logs.denyNamespaces"]; ok {
+		s.Logs.DenyNamespaces = SplitList(v)
+	}
+	if v, ok := data["logs.selector"]; ok {
+		s.Logs.LabelSelector = strings.TrimSpace(v)
+	}
+
+	// Eigene Preise gelten nur, wenn beide angegeben sind
+	if data["cost.cpuCoreHour"] != "" && data["cost.memoryGiBHour"] != "" {
+		cpu, cpuErr := strconv.ParseFloat(data["cost.cpuCoreHour"], 64)
+		memory, memErr := strconv.ParseFloat(data["cost.memoryGiBHour"], 64)
+		if cpuErr != nil || memErr != nil {
+			return defaults, fmt.Errorf("cost.cpuCoreHour/cost.memoryGiBHour müssen Zahlen sein")
+		}
+		s.Pricing = &Pricing{Source: "configmap", CPUCoreHour: cpu, MemoryGiBHour: memory}
+	}
+
+	return s, nil
+}
+
+// SplitList macht aus "a, b,,c" die Liste [a b c]
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// settings gibt die aktuell gültigen Einstellungen zurück
+func (a *Agent) settings() Settings {
+	return *a.current.Load()
+}
+
+// loadSettings liest die ConfigMap einmal, z.B. beim Start. Ohne ConfigMap gelten die Defaults.
+func (a *Agent) loadSettings(ctx context.Context) {
+	cm, err := a.clientset.CoreV1().ConfigMaps(a.config.ConfigMapNamespace).Get(ctx, a.config.ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Printf("ConfigMap %s/%s nicht lesbar, nutze Defaults: %v", a.config.ConfigMapNamespace, a.config.ConfigMapName, err)
+		}
+		return
+	}
+	a.applySettings(cm.Data)
+}
+
+// watchSettings beobachtet die ConfigMap und übernimmt Änderungen ohne Neustart
+func (a *Agent) watchSettings(ctx context.Context) {
+	opts := metav1.ListOptions{FieldSelector: "metadata.name=" + a.config.ConfigMapName}
+
+	for ctx.Err() == nil {
+		w, err := a.clientset.CoreV1().ConfigMaps(a.config.ConfigMapNamespace).Watch(ctx, opts)
+		if err != nil {
+			log.Printf("ConfigMap-Watch fehlgeschlagen: %v", err)
+			sleepCtx(ctx, time.Minute)
+			continue
+		}
+
+		// Ohne ResourceVersion liefert der Watch zuerst den aktuellen Stand, verpasste Änderungen holen wir so nach
+		for ev := range w.ResultChan() {
+			cm, ok := ev.Object.(*corev1.ConfigMap)
+			if !ok {
+				continue
+			}
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				a.applySettings(cm.Data)
+			case watch.Deleted:
+				a.applySettings(nil)
+			}
+		}
+		w.Stop()
+	}
+}
+
+// applySettings übernimmt neue Einstellungen und weckt die Run-Schleife, falls sich etwas geändert hat
+func (a *Agent) applySettings(data map[string]string) {
+	next, err := parseSettings(data, a.config.Defaults)
+	if err != nil {
+		// Eine kaputte ConfigMap soll den laufenden Agenten nicht lahmlegen
+		log.Printf("Ungültige Agent-Konfiguration, behalte die bisherige: %v", err)
+		return
+	}
+
+	if reflect.DeepEqual(next, a.settings()) {
+		return
+	}
+	a.current.Store(&next)
+	log.Printf("Agent-Konfiguration neu geladen: Intervall %s, Namespaces %v, Log-Level %s", next.Interval, next.Namespaces, next.LogLevel)
+
+	select {
+	case a.reload <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSettings(t *testing.T) {
+	defaults := Settings{
+		Interval:     5 * time.Minute,
+		Namespaces:   []string{"shop"},
+		LogLevel:     "info",
+		Metrics:      true,
+		Events:       true,
+		Cost:         true,
+		ScanInterval: 6 * time.Hour,
+	}
+
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    func(s *Settings) // Änderungen gegenüber den Defaults
+		wantErr bool
+	}{
+		{
+			name: "fehlende Schlüssel behalten die Flags",
+			data: nil,
+			want: func(s *Settings) {},
+		},
+		{
+			name: "leere Namespace-Liste überschreibt das Flag mit allen Namespaces",
+			data: map[string]string{"namespaces": ""},
+			want: func(s *Settings) { s.Namespaces = nil },
+		},
+		{
+			name: "leere Einzelwerte behalten die Flags",
+			data: map[string]string{"sync.interval": "", "collectors.metrics": "", "scan.interval": "", "log.level": ""},
+			want: func(s *Settings) {},
+		},
+		{
+			name: "gesetzte Werte überschreiben die Flags",
+			data: map[string]string{
+				"sync.interval":      "1m",
+				"namespaces":         "a, b,,c",
+				"log.level":          "debug",
+				"collectors.metrics": "false",
+				"collectors.events":  "false",
+				"collectors.cost":    "false",
+				"scan.interval":      "0",
+				"logs.enabled":       "true",
+				"logs.namespaces":    "a",
+				"logs.selector":      " app=shop ",
+			},
+			want: func(s *Settings) {
+				s.Interval = time.Minute
+				s.Namespaces = []string{"a", "b", "c"}
+				s.LogLevel = "debug"
+				s.Metrics, s.Events, s.Cost = false, false, false
+				s.ScanInterval = 0
+				s.Logs = LogConfig{Enabled: true, Namespaces: []string{"a"}, LabelSelector: "app=shop"}
+			},
+		},
+		{
+			name:    "ungültige Dauer",
+			data:    map[string]string{"sync.interval": "fünf Minuten"},
+			wantErr: true,
+		},
+		{
+			name:    "Intervall 0 würde den Ticker abstürzen lassen",
+			data:    map[string]string{"sync.interval": "0s"},
+			wantErr: true,
+		},
+		{
+			name:    "negativer Scan-Abstand",
+			data:    map[string]string{"scan.interval": "-1h"},
+			wantErr: true,
+		},
+		{
+			name:    "unbekanntes Log-Level",
+			data:    map[string]string{"log.level": "trace"},
+			wantErr: true,
+		},
+		{
+			name:    "kein Boolean",
+			data:    map[string]string{"collectors.events": "vielleicht"},
+			wantErr: true,
+		},
+		{
+			name: "Preise gelten nur mit beiden Schlüsseln",
+			data: map[string]string{"cost.cpuCoreHour": "0.05"},
+			want: func(s *Settings) {},
+		},
+		{
+			name: "Preise mit beiden Schlüsseln",
+			data: map[string]string{"cost.cpuCoreHour": "0.05", "cost.memoryGiBHour": "0.01"},
+			want: func(s *Settings) {
+				s.Pricing = &Pricing{Source: "configmap", CPUCoreHour: 0.05, MemoryGiBHour: 0.01}
+			},
+		},
+		{
+			name:    "Preise müssen Zahlen sein",
+			data:    map[string]string{"cost.cpuCoreHour": "billig", "cost.memoryGiBHour": "0.01"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSettings(tt.data, defaults)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Fehler erwartet, bekam %+v", got)
+				}
+				// Bei Fehlern müssen die bisherigen Einstellungen unverändert zurückkommen
+				if !reflect.DeepEqual(got, defaults) {
+					t.Errorf("bei Fehler erwartet %+v, bekam %+v", defaults, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unerwarteter Fehler: %v", err)
+			}
+
+			want := defaults
+			want.Namespaces = append([]string(nil), defaults.Namespaces...)
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("erwartet %+v, bekam %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestApplySettingsKeepsPreviousOnError(t *testing.T) {
+	a := NewAgent(nil, nil, Config{Defaults: Settings{Interval: 5 * time.Minute}})
+
+	a.applySettings(map[string]string{"sync.interval": "1m"})
+	if got := a.settings().Interval; got != time.Minute {
+		t.Fatalf("Intervall nach gültiger ConfigMap: erwartet 1m, bekam %s", got)
+	}
+
+	// Eine kaputte ConfigMap darf weder auf die Defaults zurückfallen noch die laufenden Einstellungen ändern
+	a.applySettings(map[string]string{"sync.interval": "kaputt"})
+	if got := a.settings().Interval; got != time.Minute {
+		t.Errorf("Intervall nach ungültiger ConfigMap: erwartet 1m, bekam %s", got)
+	}
+}
+
+func TestLogConfigAllows(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       LogConfig
+		namespace string
+		want      bool
+	}{
+		{"leere Listen erlauben alles", LogConfig{}, "shop", true},
+		{"Allow-Liste erlaubt", LogConfig{Namespaces: []string{"shop"}}, "shop", true},
+		{"nicht in der Allow-Liste", LogConfig{Namespaces: []string{"shop"}}, "billing", false},
+		{"Deny ohne Allow", LogConfig{DenyNamespaces: []string{"kube-system"}}, "kube-system", false},
+		{"Deny hat Vorrang vor Allow", LogConfig{Namespaces: []string{"shop"}, DenyNamespaces: []string{"shop"}}, "shop", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.allows(tt.namespace); got != tt.want {
+				t.Errorf("allows(%q) = %v, erwartet %v", tt.namespace, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsLogsAllow(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  Settings
+		namespace string
+		want      bool
+	}{
+		{"ohne beobachtete Namespaces gelten nur die Log-Listen", Settings{}, "shop", true},
+		{"beobachteter Namespace", Settings{Namespaces: []string{"shop"}}, "shop", true},
+		{"nicht beobachteter Namespace", Settings{Namespaces: []string{"shop"}}, "billing", false},
+		{"logs.namespaces erweitert die beobachteten nicht", Settings{Namespaces: []string{"shop"}, Logs: LogConfig{Namespaces: []string{"billing"}}}, "billing", false},
+		{"Deny gilt auch für beobachtete", Settings{Namespaces: []string{"shop"}, Logs: LogConfig{DenyNamespaces: []string{"shop"}}}, "shop", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.logsAllow(tt.namespace); got != tt.want {
+				t.Errorf("logsAllow(%q) = %v, erwartet %v", tt.namespace, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const hoursPerMonth = 730
+
+// Pricing ist der Preis pro CPU-Kern und GiB Speicher und Stunde
+type Pricing struct {
+	Source        string  `json:"source"` // "aws", "gcp", "azure", "on-prem" oder "configmap"
+	CPUCoreHour   float64 `json:"cpuCoreHour"`
+	MemoryGiBHour float64 `json:"memoryGiBHour"`
+}
+
+// defaultPricing sind grobe On-Demand-Listenpreise in USD, gedacht für Schätzungen, nicht für Abrechnungen
+var defaultPricing = map[string]Pricing{
+	"aws":     {Source: "aws", CPUCoreHour: 0.0316, MemoryGiBHour: 0.0042},
+	"gcp":     {Source: "gcp", CPUCoreHour: 0.0316, MemoryGiBHour: 0.0042},
+	"azure":   {Source: "azure", CPUCoreHour: 0.0340, MemoryGiBHour: 0.0045},
+	"on-prem": {Source: "on-prem", CPUCoreHour: 0.0250, MemoryGiBHour: 0.0035},
+}
+
+// Cost ist die Kostenschätzung des Clusters pro Namespace und Workload
+type Cost struct {
+	CollectedAt time.Time                `json:"collectedAt"`
+	Currency    string                   `json:"currency"`
+	Pricing     Pricing                  `json:"pricing"`
+	Namespaces  []map[string]interface{} `json:"namespaces"`
+	Workloads   []map[string]interface{} `json:"workloads"`
+}
+
+// workloadUsage sammelt die abgerechneten Ressourcen einer Workload
+type workloadUsage struct {
+	namespace, kind, name string
+	cpuMillicores         int64
+	memoryBytes           int64
+}
+
+// estimateCost kombiniert Requests und tatsächlichen Verbrauch mit der Preistabelle.
+// Abgerechnet wird pro Pod der größere Wert aus Request und Verbrauch, denn reservierte Kapazität kostet auch ungenutzt.
+// metrics darf nil sein, dann zählen nur die Requests. Ohne override gelten die Standardpreise.
+// Geschätzt wird nur für die angegebenen Namespaces, leer bedeutet: alle.
+func (a *Agent) estimateCost(ctx context.Context, metrics *Metrics, override *Pricing, namespaces []string) (*Cost, error) {
+	pricing, err := a.detectPricing(ctx, override)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := a.listPods(ctx, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Auflisten der Pods: %w", err)
+	}
+
+	// Verbrauch pro Pod aus den Metriken nachschlagen können
+	usage := map[string]map[string]interface{}{}
+	if metrics != nil {
+		for _, p := range metrics.Pods {
+			usage[fmt.Sprintf("%s/%s", p["namespace"], p["name"])] = p
+		}
+	}
+
+	workloads := map[string]*workloadUsage{}
+	var order []string
+	for _, pod := range pods {
+		// Abgeschlossene Pods belegen keine Ressourcen mehr
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		cpu, memory := podRequests(pod)
+		if u, ok := usage[pod.Namespace+"/"+pod.Name]; ok {
+			cpu = max(cpu, u["cpuMillicores"].(int64))
+			memory = max(memory, u["memoryBytes"].(int64))
+		}
+
+		kind, name := workloadOf(pod)
+		key := pod.Namespace + "/" + kind + "/" + name
+		w, ok := workloads[key]
+		if !ok {
+			w = &workloadUsage{namespace: pod.Namespace, kind: kind, name: name}
+			workloads[key] = w
+			order = append(order, key)
+		}
+		w.cpuMillicores += cpu
+		w.memoryBytes += memory
+	}
+
+	cost := &Cost{CollectedAt: time.Now().UTC(), Currency: "USD", Pricing: pricing}
+	namespaceHourly := map[string]float64{}
+	var namespaceOrder []string
+	for _, key := range order {
+		w := workloads[key]
+		cpuCores := float64(w.cpuMillicores) / 1000
+		memoryGiB := float64(w.memoryBytes) / (1 << 30)
+		hourly := cpuCores*pricing.CPUCoreHour + memoryGiB*pricing.MemoryGiBHour
+
+		cost.Workloads = append(cost.Workloads, map[string]interface{}{
+			"namespace": w.namespace,
+			"kind":      w.kind,
+			"name":      w.name,
+			"cpuCores":  roundCost(cpuCores),
+			"memoryGiB": roundCost(memoryGiB),
+			"hourly":    roundCost(hourly),
+			"monthly":   roundCost(hourly * hoursPerMonth),
+		})
+
+		if _, ok := namespaceHourly[w.namespace]; !ok {
+			namespaceOrder = append(namespaceOrder, w.namespace)
+		}
+		namespaceHourly[w.namespace] += hourly
+	}
+
+	for _, ns := range namespaceOrder {
+		cost.Namespaces = append(cost.Namespaces, map[string]interface{}{
+			"namespace": ns,
+			"hourly":    roundCost(namespaceHourly[ns]),
+			"monthly":   roundCost(namespaceHourly[ns] * hoursPerMonth),
+		})
+	}
+	return cost, nil
+}
+
+// detectPricing nimmt die Preise aus der ConfigMap, sonst die Standardpreise des erkannten Cloud-Anbieters
+func (a *Agent) detectPricing(ctx context.Context, override *Pricing) (Pricing, error) {
+	if override != nil {
+		return *override, nil
+	}
+
+	nodes, err := a.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return Pricing{}, fmt.Errorf("fehler beim Auflisten der Nodes: %w", err)
+	}
+	if len(nodes.Items) == 0 {
+		return defaultPricing["on-prem"], nil
+	}
+	return defaultPricing[providerOf(nodes.Items[0].Spec.ProviderID)], nil
+}
+
+// providerOf leitet den Cloud-Anbieter aus der ProviderID eines Nodes ab (z.B. "aws:///eu-central-1a/i-0abc")
+func providerOf(providerID string) string {
+	switch {
+	case strings.HasPrefix(providerID, "aws://"):
+		return "aws"
+	case strings.HasPrefix(providerID, "gce://"):
+		return "gcp"
+	case strings.HasPrefix(providerID, "azure://"):
+		return "azure"
+	default:
+		return "on-prem"
+	}
+}
+
+// podRequests summiert die Requests aller Container eines Pods
+func podRequests(pod corev1.Pod) (cpuMillicores, memoryBytes int64) {
+	for _, c := range pod.Spec.Containers {
+		cpuMillicores += c.Resources.Requests.Cpu().MilliValue()
+		memoryBytes += c.Resources.Requests.Memory().Value()
+	}
+	return cpuMillicores, memoryBytes
+}
+
+// workloadOf findet die Workload hinter einem Pod, bei Deployments über das ReplicaSet hinweg
+func workloadOf(pod corev1.Pod) (kind, name string) {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil {
+		return "Pod", pod.Name
+	}
+
+	// Ein ReplicaSet heißt "<deployment>-<pod-template-hash>"
+	if hash := pod.Labels["pod-template-hash"]; owner.Kind == "ReplicaSet" && hash != "" {
+		return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+	}
+	return owner.Kind, owner.Name
+}
+
+func roundCost(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
@@ -0,0 +1,200 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var eventGVR = schema.GroupVersionResource{
+	Group:    "", // Core API
+	Version:  "v1",
+	Resource: "events",
+}
+
+const (
+	eventFlushInterval = 5 * time.Second  // So oft werden gesammelte Events gebündelt verschickt
+	eventDedupWindow   = 10 * time.Minute // Gleiche Events innerhalb dieses Fensters werden nur einmal gemeldet
+	eventRateLimit     = 10               // Maximal so viele Events pro Sekunde ...
+	eventRateBurst     = 50               // ... mit diesem Puffer für kurze Spitzen
+	eventMaxBackoff    = time.Minute      // Längste Pause zwischen zwei fehlgeschlagenen Listen
+)
+
+// eventStreamer sammelt Warning-Events, filtert Duplikate und drosselt die Menge
+type eventStreamer struct {
+	mu      sync.Mutex
+	pending []map[string]interface{}
+	seen    map[string]time.Time // Dedup-Schlüssel -> Zeitpunkt der letzten Meldung
+	dropped int                  // Vom Rate-Limit verworfene Events seit dem letzten Flush
+	limiter *rate.Limiter
+}
+
+func newEventStreamer() *eventStreamer {
+	return &eventStreamer{
+		seen:    map[string]time.Time{},
+		limiter: rate.NewLimiter(eventRateLimit, eventRateBurst),
+	}
+}
+
+// streamEvents beobachtet Warning-Events und schickt sie nahezu in Echtzeit an die API
+func (a *Agent) streamEvents(ctx context.Context) {
+	streamer := newEventStreamer()
+
+	namespaces := a.settings().Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""} // "" beobachtet alle Namespaces
+	}
+	for _, ns := range namespaces {
+		go a.watchEvents(ctx, ns, streamer)
+	}
+
+	ticker := time.NewTicker(eventFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.flushEvents(ctx, streamer); err != nil {
+				log.Printf("Events konnten nicht gemeldet werden: %v", err)
+			}
+		}
+	}
+}
+
+// watchEvents hält einen Watch auf einen Namespace offen und baut ihn nach Abbrüchen neu auf
+func (a *Agent) watchEvents(ctx context.Context, namespace string, streamer *eventStreamer) {
+	opts := metav1.ListOptions{FieldSelector: "type=Warning"}
+	backoff := eventFlushInterval
+
+	for ctx.Err() == nil {
+		// Erst listen, damit wir beim Start keine alten Events erneut melden
+		if opts.ResourceVersion == "" {
+			list, err := a.client.Resource(eventGVR).Namespace(namespace).List(ctx, opts)
+			if err != nil {
+				log.Printf("Events in %q nicht lesbar, neuer Versuch in %s: %v", namespace, backoff, err)
+				sleepCtx(ctx, backoff)
+				// Bei anhaltenden Fehlern (z.B. fehlende RBAC) den API-Server nicht alle paar Sekunden fragen
+				backoff = min(2*backoff, eventMaxBackoff)
+				continue
+			}
+			opts.ResourceVersion = list.GetResourceVersion()
+			backoff = eventFlushInterval
+		}
+
+		w, err := a.client.Resource(eventGVR).Namespace(namespace).Watch(ctx, opts)
+		if err != nil {
+			log.Printf("Event-Watch in %q fehlgeschlagen: %v", namespace, err)
+			opts.ResourceVersion = ""
+			sleepCtx(ctx, eventFlushInterval)
+			continue
+		}
+
+		for ev := range w.ResultChan() {
+			if ev.Type == watch.Error {
+				// Meist ist die ResourceVersion zu alt, also neu listen
+				opts.ResourceVersion = ""
+				break
+			}
+			item, ok := ev.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			opts.ResourceVersion = item.GetResourceVersion()
+			if ev.Type == watch.Added || ev.Type == watch.Modified {
+				streamer.add(formatEvent(item))
+			}
+		}
+		w.Stop()
+	}
+}
+
+func (a *Agent) flushEvents(ctx context.Context, streamer *eventStreamer) error {
+	events, dropped := streamer.drain()
+	if len(events) == 0 && dropped == 0 {
+		return nil
+	}
+
+	payload := map[string]interface{}{
+		"events":  events,
+		"dropped": dropped,
+	}
+	if err := a.api.post(ctx, "/api/v1/agent/events", payload); err != nil {
+		return fmt.Errorf("fehler beim Melden von %d Events: %w", len(events), err)
+	}
+	a.debugf("%d Events gemeldet, %d durch Rate-Limit verworfen", len(events), dropped)
+	return nil
+}
+
+// add übernimmt ein Event, sofern es kein Duplikat ist und das Rate-Limit es zulässt
+func (s *eventStreamer) add(event map[string]interface{}) {
+	key := fmt.Sprintf("%s/%s/%s/%s", event["namespace"], event["kind"], event["name"], event["reason"])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.seen[key]; ok && time.Since(last) < eventDedupWindow {
+		return
+	}
+	if !s.limiter.Allow() {
+		s.dropped++
+		return
+	}
+	s.seen[key] = time.Now()
+	s.pending = append(s.pending, event)
+}
+
+// drain gibt alle gesammelten Events zurück und räumt abgelaufene Dedup-Einträge auf
+func (s *eventStreamer) drain() ([]map[string]interface{}, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, dropped := s.pending, s.dropped
+	s.pending, s.dropped = nil, 0
+
+	for key, last := range s.seen {
+		if time.Since(last) >= eventDedupWindow {
+			delete(s.seen, key)
+		}
+	}
+	return events, dropped
+}
+
+func formatEvent(item *unstructured.Unstructured) map[string]interface{} {
+	kind, _, _ := unstructured.NestedString(item.Object, "involvedObject", "kind")
+	name, _, _ := unstructured.NestedString(item.Object, "involvedObject", "name")
+	reason, _, _ := unstructured.NestedString(item.Object, "reason")
+	message, _, _ := unstructured.NestedString(item.Object, "message")
+	count, _, _ := unstructured.NestedInt64(item.Object, "count")
+	lastSeen, _, _ := unstructured.NestedString(item.Object, "lastTimestamp")
+	if lastSeen == "" {
+		// Neuere Events setzen nur eventTime
+		lastSeen, _, _ = unstructured.NestedString(item.Object, "eventTime")
+	}
+
+	return map[string]interface{}{
+		"namespace": item.GetNamespace(),
+		"kind":      kind,   // z.B. Pod
+		"name":      name,   // Name des betroffenen Objekts
+		"reason":    reason, // z.B. FailedScheduling, BackOff, OOMKilling
+		"message":   message,
+		"count":     count,
+		"lastSeen":  lastSeen,
+	}
+}
+
+// sleepCtx wartet die angegebene Zeit oder bis der Context beendet wird
+func sleepCtx(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var nodeGVR = schema.GroupVersionResource{
+	Group:    "", // Core API
+	Version:  "v1",
+	Resource: "nodes",
+}
+
+var namespaceGVR = schema.GroupVersionResource{
+	Group:    "", // Core API
+	Version:  "v1",
+	Resource: "namespaces",
+}
+
+var deploymentGVR = schema.GroupVersionResource{
+	Group:    "apps",
+	Version:  "v1",
+	Resource: "deployments",
+}
+
+var podGVR = schema.GroupVersionResource{
+	Group:    "", // Core API
+	Version:  "v1",
+	Resource: "pods",
+}
+
+// Snapshot ist der Inventar-Stand des Clusters zu einem Zeitpunkt
+type Snapshot struct {
+	CollectedAt time.Time                `json:"collectedAt"`
+	Nodes       []map[string]interface{} `json:"nodes"`
+	Namespaces  []map[string]interface{} `json:"namespaces"`
+	Deployments []map[string]interface{} `json:"deployments"`
+	Pods        []map[string]interface{} `json:"pods"`
+}
+
+// collectInventory listet die Core-Ressourcen und baut daraus einen Snapshot.
+// Ist namespaces leer, wird der ganze Cluster erfasst, sonst nur die angegebenen Namespaces.
+func (a *Agent) collectInventory(ctx context.Context, namespaces []string) (*Snapshot, error) {
+	snapshot := &Snapshot{CollectedAt: time.Now().UTC()}
+
+	nodes, err := a.client.Resource(nodeGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Auflisten der Nodes: %w", err)
+	}
+	for _, item := range nodes.Items {
+		kubeletVersion, _, _ := unstructured.NestedString(item.Object, "status", "nodeInfo", "kubeletVersion")
+		providerID, _, _ := unstructured.NestedString(item.Object, "spec", "providerID")
+
+		snapshot.Nodes = append(snapshot.Nodes, map[string]interface{}{
+			"name":           item.GetName(),
+			"labels":         item.GetLabels(),
+			"providerID":     providerID,
+			"kubeletVersion": kubeletVersion,
+			"ready":          conditionIsTrue(item, "Ready"),
+		})
+	}
+
+	namespaceList, err := a.client.Resource(namespaceGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Auflisten der Namespaces: %w", err)
+	}
+	for _, item := range namespaceList.Items {
+		if len(namespaces) > 0 && !slices.Contains(namespaces, item.GetName()) {
+			continue
+		}
+		phase, _, _ := unstructured.NestedString(item.Object, "status", "phase")
+
+		snapshot.Namespaces = append(snapshot.Namespaces, map[string]interface{}{
+			"name":   item.GetName(),
+			"labels": item.GetLabels(),
+			"phase":  phase,
+		})
+	}
+
+	deployments, err := a.listNamespaced(ctx, deploymentGVR, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Auflisten der Deployments: %w", err)
+	}
+	for _, item := range deployments {
+		replicas, _, _ := unstructured.NestedInt64(item.Object, "spec", "replicas")
+		readyReplicas, _, _ := unstructured.NestedInt64(item.Object, "status", "readyReplicas")
+
+		snapshot.Deployments = append(snapshot.Deployments, map[string]interface{}{
+			"namespace":     item.GetNamespace(),
+			"name":          item.GetName(),
+			"replicas":      replicas,
+			"readyReplicas": readyReplicas,
+		})
+	}
+
+	pods, err := a.listNamespaced(ctx, podGVR, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("fehler beim Auflisten der Pods: %w", err)
+	}
+	for _, item := range pods {
+		phase, _, _ := unstructured.NestedString(item.Object, "status", "phase")
+		nodeName, _, _ := unstructured.NestedString(item.Object, "spec", "nodeName")
+
+		snapshot.Pods = append(snapshot.Pods, map[string]interface{}{
+			"namespace": item.GetNamespace(),
+			"name":      item.GetName(),
+			"nodeName":  nodeName,
+			"phase":     phase,
+		})
+	}
+
+	return snapshot, nil
+}
+
+// listNamespaced listet eine Ressource in den angegebenen Namespaces, bei leerer Liste clusterweit
+func (a *Agent) listNamespaced(ctx context.Context, gvr schema.GroupVersionResource, namespaces []string) ([]unstructured.Unstructured, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{""} // .Namespace("") listet über alle Namespaces hinweg
+	}
+
+	var items []unstructured.Unstructured
+	for _, ns := range namespaces {
+		list, err := a.client.Resource(gvr).Namespace(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, list.Items...)
+	}
+	return items, nil
+}
+
+// listPods listet Pods typisiert in den angegebenen Namespaces, bei leerer Liste clusterweit
+func (a *Agent) listPods(ctx context.Context, namespaces []string) ([]corev1.Pod, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	var pods []corev1.Pod
+	for _, ns := range namespaces {
+		list, err := a.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, list.Items...)
+	}
+	return pods, nil
+}
+
+// conditionIsTrue prüft, ob eine Condition im Status auf "True" steht
+func conditionIsTrue(item unstructured.Unstructured, conditionType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(item.Object, "status", "conditions")
+	for _, c := range conditions {
+		if cMap, ok := c.(map[string]interface{}); ok && cMap["type"] == conditionType {
+			return cMap["status"] == "True"
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	leaseDuration = 15 * time.Second // So lange gilt ein Leader ohne Erneuerung
+	renewDeadline = 10 * time.Second // Bis dahin muss der Leader die Lease erneuert haben
+	retryPeriod   = 2 * time.Second  // So oft versuchen Standbys, die Lease zu übernehmen
+)
+
+// runWithLeaderElection bewirbt sich über eine Lease um die Leitung. Nur der Leader sammelt und meldet,
+// die anderen Replikas laufen weiter mit und übernehmen, sobald der Leader ausfällt.
+func (a *Agent) runWithLeaderElection(ctx context.Context) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      a.config.LeaseName,
+			Namespace: a.config.ConfigMapNamespace,
+		},
+		Client: a.clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: a.config.Identity,
+		},
+	}
+
+	// RunOrDie kehrt zurück, wenn die Leitung verloren geht. Danach bewerben wir uns erneut.
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   leaseDuration,
+			RenewDeadline:   renewDeadline,
+			RetryPeriod:     retryPeriod,
+			ReleaseOnCancel: true, // Beim Herunterfahren sofort freigeben, statt die Lease ablaufen zu lassen
+			Name:            a.config.LeaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(leaderCtx context.Context) {
+					log.Printf("👑 %s ist jetzt Leader und übernimmt die Syncs", a.config.Identity)
+					a.lead(leaderCtx)
+				},
+				OnStoppedLeading: func() {
+					log.Printf("%s ist nicht mehr Leader, wechselt in Standby", a.config.Identity)
+				},
+				OnNewLeader: func(identity string) {
+					if identity != a.config.Identity {
+						log.Printf("Standby, aktueller Leader ist %s", identity)
+					}
+				},
+			},
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	logDiscoveryInterval = 30 * time.Second // So oft wird nach neuen Pods/Containern gesucht
+	logFlushInterval     = 10 * time.Second // So oft werden gesammelte Zeilen verschickt
+	logMaxBatchLines     = 2000             // Ab dieser Menge wird sofort verschickt
+	logMaxLineLength     = 16 * 1024        // Längere Zeilen werden abgeschnitten
+)
+
+// LogConfig legt fest, welche Workloads der Agent mitliest
+type LogConfig struct {
+	Enabled        bool
+	Namespaces     []string // Erlaubte Namespaces, leer bedeutet: alle
+	DenyNamespaces []string // Haben Vorrang vor Namespaces
+	LabelSelector  string   // z.B. "app=shop,tier!=cache"
+}
+
+// allows prüft die Allow-/Deny-Listen für einen Namespace
+func (c LogConfig) allows(namespace string) bool {
+	for _, ns := range c.DenyNamespaces {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(c.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range c.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// logsAllow prüft, ob Logs eines Namespaces gelesen werden dürfen: Er muss beobachtet sein und die Listen der
+// Log-Sammlung passieren. logs.namespaces kann die beobachteten Namespaces also nur weiter einschränken.
+func (s Settings) logsAllow(namespace string) bool {
+	if len(s.Namespaces) > 0 && !slices.Contains(s.Namespaces, namespace) {
+		return false
+	}
+	return s.Logs.allows(namespace)
+}
+
+// logTailer folgt den Logs der ausgewählten Container und sammelt die Zeilen
+type logTailer struct {
+	mu        sync.Mutex
+	following map[string]*logFollower // "namespace/pod/container" -> laufender Follower
+	lastSeen  map[string]time.Time    // "namespace/pod/container" -> Zeitstempel der letzten gemeldeten Zeile
+	pending   []map[string]interface{}
+	flush     chan struct{} // Signalisiert, dass ein Batch voll ist
+}
+
+// logFollower ist ein laufender Log-Stream, den die Suche bei geänderten Einstellungen beenden kann
+type logFollower struct {
+	cancel context.CancelFunc
+}
+
+// tailLogs entdeckt regelmäßig passende Container, folgt ihren Logs und verschickt die Zeilen gebündelt
+func (a *Agent) tailLogs(ctx context.Context) {
+	tailer := &logTailer{
+		following: map[string]*logFollower{},
+		lastSeen:  map[string]time.Time{},
+		flush:     make(chan struct{}, 1),
+	}
+
+	discover := time.NewTicker(logDiscoveryInterval)
+	defer discover.Stop()
+	flush := time.NewTicker(logFlushInterval)
+	defer flush.Stop()
+
+	a.discoverLogTargets(ctx, tailer)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-discover.C:
+			a.discoverLogTargets(ctx, tailer)
+		case <-flush.C:
+			a.flushLogs(ctx, tailer)
+		case <-tailer.flush:
+			a.flushLogs(ctx, tailer)
+		}
+	}
+}
+
+func (a *Agent) discoverLogTargets(ctx context.Context, tailer *logTailer) {
+	// Die Einstellungen werden bei jeder Suche neu gelesen, ConfigMap-Änderungen greifen also ohne Neustart
+	s := a.settings()
+	cfg := s.Logs
+	if !cfg.Enabled {
+		tailer.retain(nil, func(string, string) bool { return false })
+		return
+	}
+
+	selector, err := labels.Parse(cfg.LabelSelector)
+	if err != nil {
+		log.Printf("logs.selector %q ist ungültig: %v", cfg.LabelSelector, err)
+		return
+	}
+
+	// Der Selector wird hier statt im API-Server geprüft, damit wir nicht mehr passende Pods von beendeten unterscheiden können
+	pods, err := a.listPods(ctx, s.Namespaces)
+	if err != nil {
+		// Ohne aktuelle Liste lassen wir laufende Streams weiterlaufen, statt alles abzubrechen
+		log.Printf("Pods für Log-Sammlung nicht lesbar: %v", err)
+		return
+	}
+
+	wanted := map[string]bool{}   // Laufende, erlaubte Container
+	excluded := map[string]bool{} // Laufende Container, die die Einstellungen nicht (mehr) erlauben
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		allowed := s.logsAllow(pod.Namespace) && selector.Matches(labels.Set(pod.Labels))
+		for _, status := range pod.Status.ContainerStatuses {
+			key := logKey(pod.Namespace, pod.Name, status.Name)
+			if !allowed {
+				excluded[key] = true
+				continue
+			}
+			// Wartende Container (z.B. CrashLoopBackOff) haben noch keinen Stream, die nächste Suche holt sie nach
+			if status.State.Running == nil {
+				continue
+			}
+			wanted[key] = true
+			if followCtx, follower, ok := tailer.start(ctx, key); ok {
+				startedAt := status.State.Running.StartedAt.Time
+				go a.followContainer(followCtx, tailer, follower, key, pod.Namespace, pod.Name, status.Name, startedAt)
+			}
+		}
+	}
+
+	// Was nicht mehr erlaubt ist (Deny-Liste, Selector, Namespaces), wird sofort beendet und nicht mehr gemeldet.
+	// Die letzten Zeilen beendeter oder gelöschter Pods bleiben dagegen stehen, sie sind oft die wichtigsten.
+	tailer.retain(wanted, func(key, namespace string) bool {
+		return !excluded[key] && s.logsAllow(namespace)
+	})
+}
+
+// logKey ist der Schlüssel eines Containers in logTailer.following
+func logKey(namespace, pod, container string) string {
+	return namespace + "/" + pod + "/" + container
+}
+
+// followContainer liest den Log-Stream eines Containers, bis er endet (Pod gelöscht, Container neu gestartet).
+// Gelesen wird ab dem Start des Containers, damit auch die ersten Zeilen vor der nächsten Suche ankommen. War der
+// Container schon einmal verfolgt, geht es nach der zuletzt gemeldeten Zeile weiter.
+func (a *Agent) followContainer(ctx context.Context, tailer *logTailer, follower *logFollower, key, namespace, pod, container string, startedAt time.Time) {
+	// Nach dem Ende vergessen wir den Container, die nächste Suche startet ihn bei einem Neustart wieder
+	defer tailer.stop(key, follower)
+
+	last := tailer.last(key)
+	since := metav1.NewTime(startedAt)
+	if last.After(startedAt) {
+		since = metav1.NewTime(last)
+	}
+	stream, err := a.clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container:  container,
+		Follow:     true,
+		Timestamps: true,
+		SinceTime:  &since,
+	}).Stream(ctx)
+	if err != nil {
+		log.Printf("Logs von %s nicht lesbar: %v", key, err)
+		return
+	}
+	defer stream.Close()
+
+	reader := bufio.NewReaderSize(stream, 64*1024)
+	for {
+		line, err := readLogLine(reader)
+		if err != nil {
+			// Beim Abbruch durch retain oder beim Beenden ist der Fehler erwartet
+			if err != io.EOF && ctx.Err() == nil {
+				log.Printf("Log-Stream von %s abgebrochen: %v", key, err)
+			}
+			return
+		}
+
+		// Mit Timestamps: true beginnt jede Zeile mit "<RFC3339Nano> "
+		timestamp, message, _ := strings.Cut(line, " ")
+
+		// SinceTime hat nur Sekundengenauigkeit, bereits gemeldete Zeilen derselben Sekunde kommen also noch einmal
+		at, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err == nil && !at.After(last) {
+			continue
+		}
+		tailer.add(key, at, map[string]interface{}{
+			"namespace": namespace,
+			"pod":       pod,
+			"container": container,
+			"timestamp": timestamp,
+			"message":   message,
+		})
+	}
+}
+
+// readLogLine liest eine Zeile ohne Zeilenumbruch. Was über logMaxLineLength hinausgeht, wird verworfen, ohne ein
+// UTF-8-Zeichen zu zerteilen; auch sehr lange Zeilen beenden den Stream also nicht.
+func readLogLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		// Ein paar Bytes über der Grenze reichen, um das letzte Zeichen vollständig zu sehen
+		if room := logMaxLineLength + utf8.UTFMax - len(line); room > 0 {
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return "", err
+		}
+
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if len(line) > logMaxLineLength {
+			cut := logMaxLineLength
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			line = line[:cut]
+		}
+		return string(line), nil
+	}
+}
+
+func (a *Agent) flushLogs(ctx context.Context, tailer *logTailer) {
+	lines := tailer.drain()
+	if len(lines) == 0 {
+		return
+	}
+
+	if err := a.api.postCompressed(ctx, "/api/v1/agent/logs", map[string]interface{}{"lines": lines}); err != nil {
+		log.Printf("fehler beim Melden von %d Log-Zeilen: %v", len(lines), err)
+		return
+	}
+	a.debugf("%d Log-Zeilen gemeldet", len(lines))
+}
+
+// start markiert einen Container als verfolgt und meldet, ob er vorher noch nicht verfolgt wurde.
+// Der zurückgegebene Context endet, sobald retain den Container nicht mehr erlaubt.
+func (t *logTailer) start(ctx context.Context, key string) (context.Context, *logFollower, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.following[key]; ok {
+		return nil, nil, false
+	}
+	followCtx, cancel := context.WithCancel(ctx)
+	follower := &logFollower{cancel: cancel}
+	t.following[key] = follower
+	return followCtx, follower, true
+}
+
+// stop vergisst einen beendeten Follower. Wurde der Container inzwischen neu gestartet, bleibt der neue Eintrag stehen.
+func (t *logTailer) stop(key string, follower *logFollower) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	follower.cancel()
+	if t.following[key] == follower {
+		delete(t.following, key)
+	}
+}
+
+// retain beendet alle Follower, die nicht in wanted stehen. Von den noch nicht gemeldeten Zeilen bleiben nur die,
+// für die keep true liefert.
+func (t *logTailer) retain(wanted map[string]bool, keep func(key, namespace string) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, follower := range t.following {
+		if !wanted[key] {
+			follower.cancel()
+			delete(t.following, key)
+		}
+	}
+	for key := range t.lastSeen {
+		if !wanted[key] {
+			delete(t.lastSeen, key)
+		}
+	}
+
+	kept := t.pending[:0]
+	for _, line := range t.pending {
+		namespace := line["namespace"].(string)
+		if keep(logKey(namespace, line["pod"].(string), line["container"].(string)), namespace) {
+			kept = append(kept, line)
+		}
+	}
+	t.pending = kept
+}
+
+// last liefert den Zeitstempel der zuletzt gemeldeten Zeile eines Containers, ohne bisherige Zeile den Nullwert
+func (t *logTailer) last(key string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.lastSeen[key]
+}
+
+// add sammelt eine Zeile; at ist ihr Zeitstempel und bleibt beim Nullwert (nicht lesbar) unberücksichtigt
+func (t *logTailer) add(key string, at time.Time, line map[string]interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at.After(t.lastSeen[key]) {
+		t.lastSeen[key] = at
+	}
+	t.pending = append(t.pending, line)
+	if len(t.pending) >= logMaxBatchLines {
+		// Nicht blockieren, ein ausstehendes Signal reicht
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *logTailer) drain() []map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := t.pending
+	t.pending = nil
+	return lines
+}
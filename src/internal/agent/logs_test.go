@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestReadLogLine(t *testing.T) {
+	long := strings.Repeat("x", logMaxLineLength-1) + "ä" + strings.Repeat("y", 2*1024*1024)
+	reader := bufio.NewReaderSize(strings.NewReader("erste\n"+long+"\nletzte ohne Umbruch"), 64*1024)
+
+	want := []string{"erste", strings.Repeat("x", logMaxLineLength-1), "letzte ohne Umbruch"}
+	for i, w := range want {
+		got, err := readLogLine(reader)
+		if err != nil {
+			t.Fatalf("Zeile %d: unerwarteter Fehler: %v", i, err)
+		}
+		// Das "ä" passt nicht mehr vollständig hinein und darf nicht halb übrig bleiben
+		if got != w || !utf8.ValidString(got) {
+			t.Errorf("Zeile %d: erwartet %d Bytes, bekam %d Bytes", i, len(w), len(got))
+		}
+	}
+
+	if _, err := readLogLine(reader); err != io.EOF {
+		t.Errorf("am Ende io.EOF erwartet, bekam %v", err)
+	}
+}
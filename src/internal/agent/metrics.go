@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var nodeMetricsGVR = schema.GroupVersionResource{
+	Group:    "metrics.k8s.io",
+	Version:  "v1beta1",
+	Resource: "nodes",
+}
+
+var podMetricsGVR = schema.GroupVersionResource{
+	Group:    "metrics.k8s.io",
+	Version:  "v1beta1",
+	Resource: "pods",
+}
+
+// Metrics ist die aggregierte CPU-/Speicher-Auslastung des Clusters
+type Metrics struct {
+	CollectedAt time.Time                `json:"collectedAt"`
+	Source      string                   `json:"source"` // "metrics-server" oder "kubelet"
+	Nodes       []map[string]interface{} `json:"nodes"`
+	Pods        []map[string]interface{} `json:"pods"`
+	Totals      map[string]interface{}   `json:"totals"`
+}
+
+// collectMetrics fragt die metrics.k8s.io API ab und fällt, falls eingeschaltet, auf die Kubelet Summary API zurück.
+// Nodes werden immer gemeldet, Pods nur aus den angegebenen Namespaces (leer bedeutet: alle).
+func (a *Agent) collectMetrics(ctx context.Context, namespaces []string) (*Metrics, error) {
+	metrics, err := a.collectMetricsServer(ctx, namespaces)
+	if err == nil {
+		return metrics, nil
+	}
+	if !a.config.KubeletFallback {
+		return nil, fmt.Errorf("metrics-server liefert keine Metriken: %w", err)
+	}
+
+	// Ohne metrics-server fragen wir jedes Kubelet direkt über den API-Server-Proxy
+	metrics, kubeletErr := a.collectKubeletSummary(ctx, namespaces)
+	if kubeletErr != nil {
+		return nil, fmt.Errorf("weder metrics-server (%v) noch Kubelet (%v) lieferten Metriken", err, kubeletErr)
+	}
+	return metrics, nil
+}
+
+func (a *Agent) collectMetricsServer(ctx context.Context, namespaces []string) (*Metrics, error) {
+	metrics := &Metrics{CollectedAt: time.Now().UTC(), Source: "metrics-server"}
+
+	nodes, err := a.client.Resource(nodeMetricsGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range nodes.Items {
+		usage, _, _ := unstructured.NestedStringMap(item.Object, "usage")
+		metrics.Nodes = append(metrics.Nodes, usageEntry(map[string]interface{}{
+			"name": item.GetName(),
+		}, parseMillicores(usage["cpu"]), parseBytes(usage["memory"])))
+	}
+
+	pods, err := a.listNamespaced(ctx, podMetricsGVR, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range pods {
+		// Ein Pod verbraucht die Summe seiner Container
+		var cpu, memory int64
+		containers, _, _ := unstructured.NestedSlice(item.Object, "containers")
+		for _, c := range containers {
+			if cMap, ok := c.(map[string]interface{}); ok {
+				usage, _, _ := unstructured.NestedStringMap(cMap, "usage")
+				cpu += parseMillicores(usage["cpu"])
+				memory += parseBytes(usage["memory"])
+			}
+		}
+
+		metrics.Pods = append(metrics.Pods, usageEntry(map[string]interface{}{
+			"namespace": item.GetNamespace(),
+			"name":      item.GetName(),
+		}, cpu, memory))
+	}
+
+	metrics.Totals = sumUsage(metrics.Nodes)
+	return metrics, nil
+}
+
+// kubeletSummary ist der Ausschnitt von /stats/summary, den wir tatsächlich brauchen
+type kubeletSummary struct {
+	Node struct {
+		NodeName string       `json:"nodeName"`
+		CPU      kubeletCPU   `json:"cpu"`
+		Memory   kubeletBytes `json:"memory"`
+	} `json:"node"`
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		CPU    kubeletCPU   `json:"cpu"`
+		Memory kubeletBytes `json:"memory"`
+	} `json:"pods"`
+}
+
+type kubeletCPU struct {
+	UsageNanoCores int64 `json:"usageNanoCores"`
+}
+
+type kubeletBytes struct {
+	WorkingSetBytes int64 `json:"workingSetBytes"`
+}
+
+func (a *Agent) collectKubeletSummary(ctx context.Context, namespaces []string) (*Metrics, error) {
+	metrics := &Metrics{CollectedAt: time.Now().UTC(), Source: "kubelet"}
+
+	nodes, err := a.client.Resource(nodeGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range nodes.Items {
+		raw, err := a.clientset.CoreV1().RESTClient().Get().
+			AbsPath("/api/v1/nodes", item.GetName(), "proxy", "stats", "summary").
+			DoRaw(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Kubelet Summary von %s nicht erreichbar: %w", item.GetName(), err)
+		}
+
+		var summary kubeletSummary
+		if err := json.Unmarshal(raw, &summary); err != nil {
+			return nil, fmt.Errorf("Kubelet Summary von %s ungültig: %w", item.GetName(), err)
+		}
+
+		metrics.Nodes = append(metrics.Nodes, usageEntry(map[string]interface{}{
+			"name": item.GetName(),
+		}, summary.Node.CPU.UsageNanoCores/1_000_000, summary.Node.Memory.WorkingSetBytes))
+
+		for _, pod := range summary.Pods {
+			// Die Summary enthält immer alle Pods des Nodes, also selbst filtern
+			if len(namespaces) > 0 && !slices.Contains(namespaces, pod.PodRef.Namespace) {
+				continue
+			}
+			metrics.Pods = append(metrics.Pods, usageEntry(map[string]interface{}{
+				"namespace": pod.PodRef.Namespace,
+				"name":      pod.PodRef.Name,
+			}, pod.CPU.UsageNanoCores/1_000_000, pod.Memory.WorkingSetBytes))
+		}
+	}
+
+	metrics.Totals = sumUsage(metrics.Nodes)
+	return metrics, nil
+}
+
+// usageEntry ergänzt einen Eintrag um die einheitlichen Verbrauchsfelder
+func usageEntry(entry map[string]interface{}, cpuMillicores, memoryBytes int64) map[string]interface{} {
+	entry["cpuMillicores"] = cpuMillicores
+	entry["memoryBytes"] = memoryBytes
+	return entry
+}
+
+func sumUsage(entries []map[string]interface{}) map[string]interface{} {
+	var cpu, memory int64
+	for _, e := range entries {
+		cpu += e["cpuMillicores"].(int64)
+		memory += e["memoryBytes"].(int64)
+	}
+	return map[string]interface{}{
+		"cpuMillicores": cpu,
+		"memoryBytes":   memory,
+	}
+}
+
+// parseMillicores wandelt z.B. "250m" oder "123456n" in Millicores um, ungültige Werte zählen als 0
+func parseMillicores(value string) int64 {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0
+	}
+	return q.MilliValue()
+}
+
+// parseBytes wandelt z.B. "128Mi" oder "524288Ki" in Bytes um, ungültige Werte zählen als 0
+func parseBytes(value string) int64 {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0
+	}
+	return q.Value()
+}
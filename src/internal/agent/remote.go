@@ -0,0 +1,268 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	remoteReconnectMin  = 5 * time.Second
+	remoteReconnectMax  = 5 * time.Minute
+	remoteMaxLogLines   = 1000 // Obergrenze für fetch-logs, egal was das Backend anfragt
+	remoteActionTimeout = time.Minute
+)
+
+// remoteRequest ist eine Aktion, die das Backend über den Kanal anfordert
+type remoteRequest struct {
+	ID     string            `json:"id"`
+	Action string            `json:"action"`
+	Params map[string]string `json:"params"`
+}
+
+// remoteResponse ist die Antwort des Agenten auf eine remoteRequest
+type remoteResponse struct {
+	ID     string      `json:"id"`
+	Action string      `json:"action"`
+	OK     bool        `json:"ok"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// remoteAction führt eine erlaubte Aktion aus und liefert ihr Ergebnis
+type remoteAction func(a *Agent, ctx context.Context, params map[string]string) (interface{}, error)
+
+// remoteActions ist die abschließende Allowlist. Alles, was hier nicht steht, wird abgelehnt.
+var remoteActions = map[string]remoteAction{
+	"refresh-inventory": (*Agent).actionRefreshInventory,
+	"fetch-logs":        (*Agent).actionFetchLogs,
+	"connectivity-test": (*Agent).actionConnectivityTest,
+}
+
+// serveRemoteActions hält eine ausgehende WebSocket-Verbindung zum Backend offen und baut sie nach Abbrüchen neu auf
+func (a *Agent) serveRemoteActions(ctx context.Context) {
+	backoff := remoteReconnectMin
+	for ctx.Err() == nil {
+		connectedAt := time.Now()
+		if err := a.runRemoteChannel(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Remote-Kanal getrennt: %v", err)
+		}
+
+		// Hielt die Verbindung eine Weile, fangen wir wieder mit kurzer Wartezeit an
+		if time.Since(connectedAt) > remoteReconnectMax {
+			backoff = remoteReconnectMin
+		}
+		sleepCtx(ctx, backoff)
+		backoff = min(backoff*2, remoteReconnectMax)
+	}
+}
+
+func (a *Agent) runRemoteChannel(ctx context.Context) error {
+	channelURL, err := websocketURL(a.api.baseURL + "/api/v1/agent/channel")
+	if err != nil {
+		return err
+	}
+
+	config, err := websocket.NewConfig(channelURL, a.api.baseURL)
+	if err != nil {
+		return fmt.Errorf("ungültige Kanal-URL %s: %w", channelURL, err)
+	}
+	config.Header.Set("Authorization", "Bearer "+a.api.token)
+	if a.api.identity != "" {
+		config.Header.Set("X-Kubervise-Agent", a.api.identity)
+	}
+
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return fmt.Errorf("verbindung zu %s fehlgeschlagen: %w", channelURL, err)
+	}
+	defer conn.Close()
+
+	// Receive blockiert, deshalb schließen wir die Verbindung, sobald der Agent beendet wird
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	log.Printf("Remote-Kanal zu %s verbunden", channelURL)
+	for {
+		var req remoteRequest
+		if err := websocket.JSON.Receive(conn, &req); err != nil {
+			return err
+		}
+
+		resp := a.handleRemoteRequest(ctx, req)
+		if err := websocket.JSON.Send(conn, resp); err != nil {
+			return err
+		}
+	}
+}
+
+// handleRemoteRequest prüft die Allowlist, führt die Aktion aus und protokolliert jeden Aufruf
+func (a *Agent) handleRemoteRequest(ctx context.Context, req remoteRequest) remoteResponse {
+	started := time.Now()
+	resp := remoteResponse{ID: req.ID, Action: req.Action}
+
+	action, ok := remoteActions[req.Action]
+	if !ok {
+		resp.Error = fmt.Sprintf("aktion %q ist nicht erlaubt", req.Action)
+	} else {
+		actionCtx, cancel := context.WithTimeout(ctx, remoteActionTimeout)
+		result, err := action(a, actionCtx, req.Params)
+		cancel()
+
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.OK = true
+			resp.Result = result
+		}
+	}
+
+	auditRemoteAction(req, resp, time.Since(started))
+	return resp
+}
+
+// auditRemoteAction schreibt eine JSON-Zeile pro angefragter Aktion ins Log, auch für abgelehnte
+func auditRemoteAction(req remoteRequest, resp remoteResponse, duration time.Duration) {
+	entry, _ := json.Marshal(map[string]interface{}{
+		"time":       time.Now().UTC(),
+		"id":         req.ID,
+		"action":     req.Action,
+		"params":     req.Params,
+		"ok":         resp.OK,
+		"error":      resp.Error,
+		"durationMs": duration.Milliseconds(),
+	})
+	log.Printf("AUDIT remote-action %s", entry)
+}
+
+// actionRefreshInventory stößt sofort einen kompletten Sync an
+func (a *Agent) actionRefreshInventory(ctx context.Context, _ map[string]string) (interface{}, error) {
+	if err := a.Sync(ctx); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"syncedAt": time.Now().UTC()}, nil
+}
+
+// actionFetchLogs liefert die letzten Zeilen eines Containers. Es gelten dieselben Regeln wie bei der Log-Sammlung:
+// Sie muss eingeschaltet sein, und Namespace und Selector müssen den Pod erlauben.
+func (a *Agent) actionFetchLogs(ctx context.Context, params map[string]string) (interface{}, error) {
+	namespace, pod := params["namespace"], params["pod"]
+	if namespace == "" || pod == "" {
+		return nil, fmt.Errorf("namespace und pod sind Pflicht")
+	}
+
+	s := a.settings()
+	cfg := s.Logs
+	if !cfg.Enabled {
+		return nil, fmt.Errorf("log-Sammlung ist nicht eingeschaltet (logs.enabled)")
+	}
+	if !s.logsAllow(namespace) {
+		return nil, fmt.Errorf("logs aus Namespace %s sind nicht freigegeben", namespace)
+	}
+
+	selector, err := labels.Parse(cfg.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("logs.selector %q ist ungültig: %w", cfg.LabelSelector, err)
+	}
+	podObj, err := a.clientset.CoreV1().Pods(namespace).Get(ctx, pod, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("pod %s/%s nicht lesbar: %w", namespace, pod, err)
+	}
+	if !selector.Matches(labels.Set(podObj.Labels)) {
+		return nil, fmt.Errorf("logs von %s/%s sind durch logs.selector nicht freigegeben", namespace, pod)
+	}
+
+	tailLines := int64(100)
+	if v := params["tailLines"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("tailLines %q ist ungültig", v)
+		}
+		tailLines = n
+	}
+	tailLines = min(tailLines, remoteMaxLogLines)
+
+	raw, err := a.clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: params["container"], // Leer ist erlaubt, wenn der Pod nur einen Container hat
+		TailLines: &tailLines,
+		Previous:  params["previous"] == "true",
+	}).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("logs von %s/%s nicht lesbar: %w", namespace, pod, err)
+	}
+
+	return map[string]interface{}{
+		"namespace": namespace,
+		"pod":       pod,
+		"container": params["container"],
+		"lines":     strings.Split(strings.TrimRight(string(raw), "\n"), "\n"),
+	}, nil
+}
+
+// actionConnectivityTest prüft, ob der Agent den Kubernetes-API-Server und das Backend erreicht
+func (a *Agent) actionConnectivityTest(ctx context.Context, _ map[string]string) (interface{}, error) {
+	results := map[string]interface{}{}
+
+	started := time.Now()
+	version, err := a.clientset.Discovery().ServerVersion()
+	results["kubernetes"] = checkResult(started, err, func() interface{} { return version.GitVersion })
+
+	backend, err := url.Parse(a.api.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("ungültige API-URL: %w", err)
+	}
+
+	started = time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, backend.Hostname())
+	results["dns"] = checkResult(started, err, func() interface{} { return addrs })
+
+	// Jede HTTP-Antwort zählt als erreichbar, nur Verbindungsfehler (Proxy, TLS, Egress-Policy) nicht
+	started = time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, a.api.baseURL, nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = a.api.http.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	results["backend"] = checkResult(started, err, nil)
+
+	return results, nil
+}
+
+func checkResult(started time.Time, err error, detail func() interface{}) map[string]interface{} {
+	result := map[string]interface{}{
+		"ok":         err == nil,
+		"durationMs": time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		result["error"] = err.Error()
+	} else if detail != nil {
+		result["detail"] = detail()
+	}
+	return result
+}
+
+// websocketURL macht aus http(s):// die passende ws(s)://-URL
+func websocketURL(httpURL string) (string, error) {
+	switch {
+	case strings.HasPrefix(httpURL, "https://"):
+		return "wss://" + strings.TrimPrefix(httpURL, "https://"), nil
+	case strings.HasPrefix(httpURL, "http://"):
+		return "ws://" + strings.TrimPrefix(httpURL, "http://"), nil
+	default:
+		return "", fmt.Errorf("API-URL %s muss mit http:// oder https:// beginnen", httpURL)
+	}
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// severities in der Reihenfolge, in der sie gemeldet und geloggt werden
+var severities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "UNKNOWN"}
+
+// trivyReport ist der Ausschnitt aus "trivy image --format json", den wir auswerten
+type trivyReport struct {
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID string `json:"VulnerabilityID"`
+			PkgName         string `json:"PkgName"`
+			Severity        string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// ScanReport fasst die Schwachstellen aller laufenden Images zusammen
+type ScanReport struct {
+	CollectedAt time.Time                `json:"collectedAt"`
+	Images      []map[string]interface{} `json:"images"`
+	Totals      map[string]int           `json:"totals"`
+}
+
+// scanImages scannt in jedem Scan-Intervall alle laufenden Images mit Trivy
+func (a *Agent) scanImages(ctx context.Context) {
+	// Wie bei der CLI-Ausführung im Onboarder rufen wir das Binary direkt auf, statt Trivy einzubetten
+	if _, err := exec.LookPath("trivy"); err != nil {
+		log.Printf("Image-Scan deaktiviert, trivy nicht gefunden: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(a.settings().ScanInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.scanOnce(ctx); err != nil {
+			log.Printf("Image-Scan fehlgeschlagen: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Agent) scanOnce(ctx context.Context) error {
+	// Gescannt wird nur, was in den beobachteten Namespaces läuft
+	pods, err := a.listPods(ctx, a.settings().Namespaces)
+	if err != nil {
+		return fmt.Errorf("fehler beim Auflisten der Pods: %w", err)
+	}
+
+	// Jedes Image nur einmal scannen, egal wie viele Pods es nutzen
+	podsPerImage := map[string]int{}
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			podsPerImage[status.Image]++
+		}
+	}
+
+	images := make([]string, 0, len(podsPerImage))
+	for image := range podsPerImage {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	report := &ScanReport{CollectedAt: time.Now().UTC(), Totals: map[string]int{}}
+	for _, image := range images {
+		counts, critical, err := runTrivy(ctx, image)
+		if err != nil {
+			// Ein einzelnes nicht scanbares Image (z.B. privat) soll den Rest nicht aufhalten
+			log.Printf("Image %s nicht scanbar: %v", image, err)
+			report.Images = append(report.Images, map[string]interface{}{
+				"image": image,
+				"pods":  podsPerImage[image],
+				"error": err.Error(),
+			})
+			continue
+		}
+
+		for severity, n := range counts {
+			report.Totals[severity] += n
+		}
+		report.Images = append(report.Images, map[string]interface{}{
+			"image":      image,
+			"pods":       podsPerImage[image],
+			"severities": counts,
+			"critical":   critical, // IDs der kritischen CVEs, damit das Dashboard direkt verlinken kann
+		})
+	}
+
+	if err := a.api.post(ctx, "/api/v1/agent/vulnerabilities", report); err != nil {
+		return fmt.Errorf("fehler beim Melden der Scan-Ergebnisse: %w", err)
+	}
+
+	breakdown := ""
+	for _, severity := range severities {
+		breakdown += fmt.Sprintf(" %s=%d", severity, report.Totals[severity])
+	}
+	log.Printf("Image-Scan gemeldet: %d Images,%s", len(images), breakdown)
+	return nil
+}
+
+// runTrivy scannt ein Image und zählt die Schwachstellen pro Schweregrad
+func runTrivy(ctx context.Context, image string) (map[string]int, []string, error) {
+	// Image-Namen kommen aus beliebigen Tenant-Pods, sie dürfen nie als Flag interpretiert werden
+	if strings.HasPrefix(image, "-") {
+		return nil, nil, fmt.Errorf("ungültige Image-Referenz %q", image)
+	}
+
+	cmd := exec.CommandContext(ctx, "trivy", "image", "--quiet", "--format", "json", "--", image)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("trivy: %w", err)
+	}
+
+	var report trivyReport
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, nil, fmt.Errorf("trivy lieferte ungültiges JSON: %w", err)
+	}
+
+	counts := map[string]int{}
+	var critical []string
+	for _, result := range report.Results {
+		for _, vuln := range result.Vulnerabilities {
+			counts[vuln.Severity]++
+			if vuln.Severity == "CRITICAL" {
+				critical = append(critical, vuln.VulnerabilityID)
+			}
+		}
+	}
+	return counts, critical, nil
+}